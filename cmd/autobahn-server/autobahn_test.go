@@ -0,0 +1,138 @@
+//go:build autobahn
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// allowList names Autobahn case ids this server is documented to not pass
+// strictly. Any case outside allowList must report OK/OK_STRICT or the test
+// fails.
+var allowList = map[string]bool{}
+
+// allowedPrefixes covers whole groups of cases that don't apply because gbs
+// doesn't implement the feature they exercise, rather than one-off corners:
+//   - group 6 exercises UTF-8 validation on text frames, which gbs does not
+//     perform, so invalid-UTF-8 cases are echoed back instead of rejected.
+//   - groups 12 and 13 negotiate and exercise permessage-deflate, which gbs
+//     does not implement, so the server never advertises the extension.
+//
+// Both are expected to report NON-STRICT or FAILED.
+var allowedPrefixes = []string{"6.", "12.", "13."}
+
+func isAllowed(caseID string) bool {
+	if allowList[caseID] {
+		return true
+	}
+	for _, prefix := range allowedPrefixes {
+		if strings.HasPrefix(caseID, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// TestAutobahn builds autobahn-server, runs it against the Autobahn
+// fuzzingclient docker image, and fails on any case outside allowList/
+// allowedPrefixes that didn't report OK.
+//
+// This is the only coverage in the module for fragmentation reassembly and
+// close-code semantics — everything else only exercises happy-path
+// text/binary and ping/pong. UTF-8 validation, extension negotiation, and
+// compression are not covered: gbs implements none of them (see
+// allowedPrefixes).
+func TestAutobahn(t *testing.T) {
+	if _, err := exec.LookPath("docker"); err != nil {
+		t.Skip("docker not available")
+	}
+
+	reportDir := t.TempDir()
+	writeSpec(t, filepath.Join(reportDir, "fuzzingclient.json"))
+
+	stopServer := startServer(t)
+	defer stopServer()
+
+	cmd := exec.Command("docker", "run", "--rm",
+		"--network=host",
+		"-v", reportDir+":/config",
+		"-v", reportDir+":/reports",
+		"crossbario/autobahn-testsuite",
+		"wstest", "-m", "fuzzingclient", "-s", "/config/fuzzingclient.json",
+	)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("autobahn fuzzingclient: %v", err)
+	}
+
+	for caseID, behavior := range parseReport(t, filepath.Join(reportDir, "servers", "index.json")) {
+		if isAllowed(caseID) {
+			continue
+		}
+		if behavior != "OK" && behavior != "OK_STRICT" {
+			t.Errorf("case %s: %s", caseID, behavior)
+		}
+	}
+}
+
+func writeSpec(t *testing.T, path string) {
+	t.Helper()
+	spec := map[string]any{
+		"outdir":  "/reports/servers",
+		"servers": []map[string]string{{"agent": "gbs", "url": "ws://localhost:9001"}},
+		"cases":   []string{"*"},
+	}
+	data, err := json.MarshalIndent(spec, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func startServer(t *testing.T) (shutdown func()) {
+	t.Helper()
+	bin := filepath.Join(t.TempDir(), "autobahn-server")
+	if out, err := exec.Command("go", "build", "-o", bin, ".").CombinedOutput(); err != nil {
+		t.Fatalf("build autobahn-server: %v\n%s", err, out)
+	}
+
+	cmd := exec.Command(bin, "-addr", ":9001")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start autobahn-server: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	return func() { _ = cmd.Process.Kill() }
+}
+
+func parseReport(t *testing.T, path string) map[string]string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var raw map[string]map[string]struct {
+		Behavior string `json:"behavior"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatal(err)
+	}
+
+	result := make(map[string]string)
+	for _, cases := range raw {
+		for caseID, c := range cases {
+			result[caseID] = c.Behavior
+		}
+	}
+	return result
+}
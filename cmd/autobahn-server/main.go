@@ -0,0 +1,42 @@
+// Command autobahn-server runs a gbs echo server configured for the
+// Autobahn fuzzingclient test suite. See autobahn_test.go for the Go test
+// that drives the fuzzingclient docker image against it.
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+
+	"github.com/catermujo/gbs"
+)
+
+type echoHandler struct {
+	gbs.BuiltinEventHandler
+}
+
+func (h *echoHandler) OnMessage(socket *gbs.Conn, message *gbs.Message) {
+	_ = socket.WriteMessage(message.Opcode, message.Bytes())
+}
+
+func (h *echoHandler) OnPing(socket *gbs.Conn, payload []byte) {
+	_ = socket.WritePong(payload)
+}
+
+func main() {
+	addr := flag.String("addr", ":9001", "address to listen on for the Autobahn fuzzingclient")
+	flag.Parse()
+
+	// CompressEnabled is left false: gbs has no permessage-deflate
+	// implementation, so claiming it here would just make Autobahn's
+	// extension-negotiation cases fail instead of being honestly skipped.
+	upgrader := gbs.NewUpgrader(new(echoHandler), &gbs.ServerOption{})
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("autobahn-server listening on %s", *addr)
+	log.Fatal(gbs.NewServer(listener, upgrader).Run())
+}
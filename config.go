@@ -0,0 +1,133 @@
+package gbs
+
+import (
+	"net"
+	"time"
+)
+
+// WriteQueuePolicy selects what Conn.WriteAsync does when the connection's
+// write queue is full (see Config.WriteQueueCap).
+type WriteQueuePolicy uint8
+
+const (
+	// BlockProducer makes the WriteAsync caller wait for room, giving real
+	// backpressure instead of letting the queue grow without bound.
+	BlockProducer WriteQueuePolicy = iota
+	// DropOldest discards the oldest not-yet-sent queued write to make room
+	// for the new one. When every slot is taken by a write already in
+	// progress, the new write is the one discarded.
+	DropOldest
+	// DropNewest discards the write that would have overflowed the queue,
+	// leaving everything already queued untouched.
+	DropNewest
+	// CloseConn closes the connection instead of queuing further writes.
+	CloseConn
+)
+
+// Config is the fully-resolved runtime configuration shared by a Conn,
+// derived from ServerOption/ClientOption via getConfig so Conn never has to
+// special-case which side it's on.
+type Config struct {
+	ParallelEnabled bool
+	ReadTimeout     time.Duration
+	CompressEnabled bool
+
+	// MaxFrameSize bounds the payload length readFrame will allocate for a
+	// single frame. Zero (the default) falls back to defaultMaxFrameSize;
+	// a frame claiming a larger length is rejected with ErrFrameTooLarge
+	// instead of allocating it.
+	MaxFrameSize int
+
+	WriteQueueCap    int
+	WriteQueuePolicy WriteQueuePolicy
+	OnDrop           func(socket *Conn, opcode Opcode, payload []byte)
+}
+
+// ServerOption configures an Upgrader and, transitively, every Conn it
+// upgrades.
+type ServerOption struct {
+	ParallelEnabled bool
+	ReadTimeout     time.Duration
+	CompressEnabled bool
+
+	// MaxFrameSize mirrors Config.MaxFrameSize.
+	MaxFrameSize int
+
+	// OnConnect runs once per raw TCP accept, immediately after Accept and
+	// before any bufio read or HTTP parsing, so callers can enforce
+	// admission control (rate limits, IP allow/deny lists, TLS-level
+	// checks) without paying for handshake buffers on connections that
+	// will be rejected anyway. A non-nil error closes netConn and fires
+	// OnError with the reason instead of attempting the handshake.
+	OnConnect func(netConn net.Conn) error
+
+	// OnError is invoked when OnConnect or the handshake itself fails for
+	// an accepted connection, with the reason the connection was closed.
+	OnError func(netConn net.Conn, err error)
+
+	// WriteQueueCap bounds how many writes Conn.WriteAsync may have queued
+	// or in flight at once. Zero (the default) leaves the queue unbounded,
+	// matching gbs's historical behavior.
+	WriteQueueCap int
+	// WriteQueuePolicy selects what happens once WriteQueueCap is reached.
+	// Zero value is BlockProducer.
+	WriteQueuePolicy WriteQueuePolicy
+	// OnDrop is invoked whenever WriteQueuePolicy drops a message rather
+	// than queuing or sending it, so callers can record metrics or signal
+	// the client to resync.
+	OnDrop func(socket *Conn, opcode Opcode, payload []byte)
+}
+
+func initServerOption(option *ServerOption) *ServerOption {
+	if option == nil {
+		option = new(ServerOption)
+	}
+	return option
+}
+
+func (c *ServerOption) getConfig() *Config {
+	return &Config{
+		ParallelEnabled:  c.ParallelEnabled,
+		ReadTimeout:      c.ReadTimeout,
+		CompressEnabled:  c.CompressEnabled,
+		MaxFrameSize:     c.MaxFrameSize,
+		WriteQueueCap:    c.WriteQueueCap,
+		WriteQueuePolicy: c.WriteQueuePolicy,
+		OnDrop:           c.OnDrop,
+	}
+}
+
+// ClientOption configures an outbound Dial.
+type ClientOption struct {
+	ParallelEnabled bool
+	ReadTimeout     time.Duration
+	CompressEnabled bool
+
+	// MaxFrameSize mirrors Config.MaxFrameSize.
+	MaxFrameSize int
+
+	// WriteQueueCap and WriteQueuePolicy mirror the same fields on
+	// ServerOption.
+	WriteQueueCap    int
+	WriteQueuePolicy WriteQueuePolicy
+	OnDrop           func(socket *Conn, opcode Opcode, payload []byte)
+}
+
+func initClientOption(option *ClientOption) *ClientOption {
+	if option == nil {
+		option = new(ClientOption)
+	}
+	return option
+}
+
+func (c *ClientOption) getConfig() *Config {
+	return &Config{
+		ParallelEnabled:  c.ParallelEnabled,
+		ReadTimeout:      c.ReadTimeout,
+		CompressEnabled:  c.CompressEnabled,
+		MaxFrameSize:     c.MaxFrameSize,
+		WriteQueueCap:    c.WriteQueueCap,
+		WriteQueuePolicy: c.WriteQueuePolicy,
+		OnDrop:           c.OnDrop,
+	}
+}
@@ -0,0 +1,161 @@
+package gbs
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// ErrUnexpectedContinuation is returned when a continuation frame (opcode
+// OpcodeContinuation) arrives with no preceding unfinished fragmented
+// message to continue.
+var ErrUnexpectedContinuation = errors.New("gbs: continuation frame without a preceding fragmented message")
+
+// ErrUnexpectedFragmentStart is returned when a new fragmented message
+// (fin=false) starts while a previous one is still being reassembled.
+var ErrUnexpectedFragmentStart = errors.New("gbs: new fragmented message started before the previous one finished")
+
+// Conn represents a single upgraded WebSocket connection, server- or
+// client-side.
+type Conn struct {
+	isServer    bool
+	ss          SessionStorage
+	config      *Config
+	conn        transport
+	closed      uint32
+	br          *bufio.Reader
+	handler     EventHandler
+	subprotocol string
+	writeQueue  workerQueue
+	readQueue   channel
+
+	// closeHooks run after handler.OnClose, in addition to it. Hub uses
+	// this to unsubscribe a Conn that disconnects without ever failing a
+	// write, e.g. an idle subscriber that simply goes away.
+	closeHooks []func(err error)
+
+	// fragOpcode and fragBuf reassemble a fragmented message: fragBuf is
+	// non-nil between the first fragment (fin=false) and the final
+	// continuation frame (fin=true) that completes it. ReadLoop is the only
+	// reader/writer of these, so no locking is needed.
+	fragOpcode Opcode
+	fragBuf    *bytes.Buffer
+}
+
+// NetConn returns the underlying network connection, or nil if this Conn is
+// backed by a non-net.Conn transport (e.g. the HTTP-streaming/SSE fallback).
+func (c *Conn) NetConn() net.Conn {
+	nc, _ := c.conn.(net.Conn)
+	return nc
+}
+
+// Session returns the per-connection key/value store.
+func (c *Conn) Session() SessionStorage {
+	return c.ss
+}
+
+func (c *Conn) isClosed() bool {
+	return atomic.LoadUint32(&c.closed) == 1
+}
+
+func (c *Conn) close(err error) {
+	if !atomic.CompareAndSwapUint32(&c.closed, 0, 1) {
+		return
+	}
+	_ = c.conn.Close()
+	if c.handler != nil {
+		c.handler.OnClose(c, err)
+	}
+	for _, fn := range c.closeHooks {
+		fn(err)
+	}
+}
+
+// onClose registers fn to run when the connection closes, after
+// handler.OnClose. It must be called before the connection can possibly
+// close already (e.g. right after construction, as Hub.Subscribe does) —
+// there's no retroactive delivery for a Conn that's already closed.
+func (c *Conn) onClose(fn func(err error)) {
+	c.closeHooks = append(c.closeHooks, fn)
+}
+
+// ReadLoop blocks reading frames off the connection and dispatching them to
+// the configured EventHandler until the connection is closed or errors.
+// Callers run it in its own goroutine.
+func (c *Conn) ReadLoop() {
+	for {
+		// Config.ReadTimeout is an idle timeout: the deadline is pushed out
+		// before every read and so only fires if no frame (not even a
+		// ping) arrives within the window, rather than bounding the whole
+		// connection lifetime.
+		if c.config != nil && c.config.ReadTimeout > 0 {
+			_ = c.conn.SetReadDeadline(time.Now().Add(c.config.ReadTimeout))
+		}
+
+		header, payload, err := c.readFrame()
+		if err != nil {
+			c.close(err)
+			return
+		}
+
+		switch header.opcode {
+		case OpcodePing:
+			if c.handler != nil {
+				c.handler.OnPing(c, payload)
+			}
+		case OpcodePong:
+			if c.handler != nil {
+				c.handler.OnPong(c, payload)
+			}
+		case OpcodeCloseConnection:
+			// RFC 6455 section 7.1.5: a peer that initiates the closing
+			// handshake must see its Close frame echoed back (ideally with
+			// the same status code) before the connection actually closes.
+			_ = c.writeFrame(OpcodeCloseConnection, payload)
+			c.close(io.EOF)
+			return
+		case OpcodeContinuation:
+			if c.fragBuf == nil {
+				c.close(ErrUnexpectedContinuation)
+				return
+			}
+			c.fragBuf.Write(payload)
+			if header.fin {
+				opcode, buf := c.fragOpcode, c.fragBuf
+				c.fragBuf = nil
+				c.dispatch(opcode, buf.Bytes())
+			}
+		default:
+			if c.fragBuf != nil {
+				c.close(ErrUnexpectedFragmentStart)
+				return
+			}
+			if !header.fin {
+				c.fragOpcode = header.opcode
+				c.fragBuf = bytes.NewBuffer(payload)
+				continue
+			}
+			c.dispatch(header.opcode, payload)
+		}
+	}
+}
+
+// dispatch hands a fully reassembled message to the configured EventHandler,
+// directly or via readQueue when Config.ParallelEnabled.
+func (c *Conn) dispatch(opcode Opcode, payload []byte) {
+	message := &Message{Opcode: opcode, Data: bytes.NewBuffer(payload)}
+	if c.config != nil && c.config.ParallelEnabled {
+		c.readQueue.Go(message, func(message *Message) error {
+			if c.handler != nil {
+				c.handler.OnMessage(c, message)
+			}
+			return nil
+		})
+	} else if c.handler != nil {
+		c.handler.OnMessage(c, message)
+	}
+}
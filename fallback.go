@@ -0,0 +1,459 @@
+package gbs
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SessionRegistry associates a fallback connection id with its live *Conn,
+// so a reconnecting POST can land on any node rather than requiring sticky
+// sessions. NewFallbackHandler falls back to an in-process, single-node
+// implementation when given nil (a FallbackHandler with a nil Registry is not
+// usable); back this with Redis or similar to fan the fallback transport out
+// across a cluster.
+type SessionRegistry interface {
+	Store(connID string, socket *Conn)
+	Load(connID string) (socket *Conn, ok bool)
+	Delete(connID string)
+}
+
+// memorySessionRegistry is the default, single-node SessionRegistry.
+type memorySessionRegistry struct {
+	mu    sync.RWMutex
+	conns map[string]*Conn
+}
+
+func newMemorySessionRegistry() *memorySessionRegistry {
+	return &memorySessionRegistry{conns: make(map[string]*Conn)}
+}
+
+func (r *memorySessionRegistry) Store(connID string, socket *Conn) {
+	r.mu.Lock()
+	r.conns[connID] = socket
+	r.mu.Unlock()
+}
+
+func (r *memorySessionRegistry) Load(connID string) (*Conn, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	socket, ok := r.conns[connID]
+	return socket, ok
+}
+
+func (r *memorySessionRegistry) Delete(connID string) {
+	r.mu.Lock()
+	delete(r.conns, connID)
+	r.mu.Unlock()
+}
+
+// maxUnackedFrames bounds how many not-yet-acknowledged outbound frames
+// httpTransport keeps around for replay, so a client that never reconnects
+// (or never acks) can't grow the buffer without bound.
+const maxUnackedFrames = 256
+
+// bufferedFrame is an outbound frame kept around in case the GET stream it
+// was written to drops before the client acknowledges it.
+type bufferedFrame struct {
+	seq  uint64
+	wire []byte
+}
+
+// httpTransport adapts the two-request HTTP fallback — a long-lived GET that
+// streams outbound frames, and POST requests that deliver inbound ones — to
+// the transport interface, so the rest of Conn (writeQueue, readQueue, frame
+// parsing) doesn't know it isn't talking to a raw net.Conn.
+type httpTransport struct {
+	connID      string
+	sse         bool
+	readTimeout time.Duration
+
+	mu         sync.Mutex
+	w          http.ResponseWriter
+	flusher    http.Flusher
+	superseded chan struct{} // closed when a newer GET replaces the current one
+	closed     bool
+
+	seq       uint64          // last sequence number assigned to a Write
+	ackCursor uint64          // highest sequence number the client has acked
+	unacked   []bufferedFrame // frames sent but not yet acked, for replay
+
+	idleTimer *time.Timer // enforces readTimeout across both GET and POST
+
+	incoming chan []byte // frame bytes delivered by ServePOST
+	pending  []byte      // unread remainder of the current incoming frame
+	closeCh  chan struct{}
+}
+
+// newHTTPTransport builds a transport for connID. readTimeout, if positive,
+// is enforced as a single idle timer spanning both the GET and POST halves:
+// it resets on every Write or delivered POST body, and closes the transport
+// (and the Conn riding on it) if it ever fires.
+func newHTTPTransport(connID string, sse bool, readTimeout time.Duration) *httpTransport {
+	t := &httpTransport{
+		connID:      connID,
+		sse:         sse,
+		readTimeout: readTimeout,
+		incoming:    make(chan []byte, 64),
+		closeCh:     make(chan struct{}),
+	}
+	if readTimeout > 0 {
+		t.idleTimer = time.AfterFunc(readTimeout, func() { _ = t.Close() })
+	}
+	t.touch()
+	return t
+}
+
+// touch pushes the idle timeout deadline back out, if one is configured.
+func (t *httpTransport) touch() {
+	if t.readTimeout > 0 && t.idleTimer != nil {
+		t.idleTimer.Reset(t.readTimeout)
+	}
+}
+
+// attachGET binds the long-lived GET response writer that streams outbound
+// frames down to the client, replaying any frame sent since the client's
+// last acknowledged sequence number so a reconnecting GET doesn't lose
+// frames written while nothing was attached. A reconnecting client's new GET
+// replaces the previous one, and the returned channel is closed the moment
+// that happens again, so the caller's keepalive loop for *this* GET knows to
+// stop even if the old request's own context never reports done.
+func (t *httpTransport) attachGET(w http.ResponseWriter) (superseded <-chan struct{}, err error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, errors.New("gbs: response writer does not support flushing")
+	}
+
+	t.mu.Lock()
+	if t.superseded != nil {
+		close(t.superseded)
+	}
+	done := make(chan struct{})
+	t.superseded = done
+	t.w = w
+	t.flusher = flusher
+	replay := make([][]byte, len(t.unacked))
+	for i, f := range t.unacked {
+		replay[i] = f.wire
+	}
+	t.mu.Unlock()
+
+	for _, wire := range replay {
+		if _, werr := w.Write(wire); werr != nil {
+			return done, werr
+		}
+	}
+	if len(replay) > 0 {
+		flusher.Flush()
+	}
+	return done, nil
+}
+
+// detachGET clears the attached GET response writer, but only if w is still
+// the one attached — a superseding reconnect already overwrote it with its
+// own writer, and clearing that would point Write at nothing despite a GET
+// being attached. Called once a GET's own request ends, so a later Write
+// buffers for replay instead of flushing through a response writer that
+// net/http has already torn down.
+func (t *httpTransport) detachGET(w http.ResponseWriter) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.w == w {
+		t.w = nil
+		t.flusher = nil
+	}
+}
+
+// updateAck advances the client's acknowledged sequence number and drops any
+// buffered frame it covers. Acks are monotonic: ack values at or below the
+// current cursor are ignored.
+func (t *httpTransport) updateAck(ack uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if ack <= t.ackCursor {
+		return
+	}
+	t.ackCursor = ack
+	i := 0
+	for i < len(t.unacked) && t.unacked[i].seq <= ack {
+		i++
+	}
+	t.unacked = t.unacked[i:]
+}
+
+// deliver pushes a frame received over POST into the transport's read side.
+func (t *httpTransport) deliver(frame []byte) {
+	t.touch()
+	select {
+	case t.incoming <- frame:
+	case <-t.closeCh:
+	}
+}
+
+func (t *httpTransport) Read(b []byte) (int, error) {
+	if len(t.pending) == 0 {
+		select {
+		case frame, ok := <-t.incoming:
+			if !ok {
+				return 0, io.EOF
+			}
+			t.pending = frame
+		case <-t.closeCh:
+			return 0, io.EOF
+		}
+	}
+	n := copy(b, t.pending)
+	t.pending = t.pending[n:]
+	return n, nil
+}
+
+// encode wraps b as one length-prefixed chunk (or one SSE event, using the
+// "id:" field for seq) addressed by seq, so attachGET can replay it verbatim
+// to a reconnecting GET.
+func (t *httpTransport) encode(seq uint64, b []byte) []byte {
+	if t.sse {
+		var buf bytes.Buffer
+		buf.WriteString("id: ")
+		buf.WriteString(strconv.FormatUint(seq, 10))
+		buf.WriteString("\ndata: ")
+		buf.Write(b)
+		buf.WriteString("\n\n")
+		return buf.Bytes()
+	}
+
+	var header [12]byte
+	binary.BigEndian.PutUint64(header[0:8], seq)
+	binary.BigEndian.PutUint32(header[8:12], uint32(len(b)))
+	buf := make([]byte, 0, len(header)+len(b))
+	buf = append(buf, header[:]...)
+	buf = append(buf, b...)
+	return buf
+}
+
+// Write sends b as one sequenced, length-prefixed chunk (or one sequenced
+// SSE event) down the attached GET stream, buffering it until the client
+// acks it (see updateAck) so a reconnecting GET can replay anything it
+// missed.
+func (t *httpTransport) Write(b []byte) (int, error) {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return 0, net.ErrClosed
+	}
+
+	t.seq++
+	wire := t.encode(t.seq, b)
+	t.unacked = append(t.unacked, bufferedFrame{seq: t.seq, wire: wire})
+	if len(t.unacked) > maxUnackedFrames {
+		t.unacked = t.unacked[len(t.unacked)-maxUnackedFrames:]
+	}
+
+	w, flusher := t.w, t.flusher
+	t.mu.Unlock()
+
+	t.touch()
+	if w == nil {
+		// No GET attached right now; the frame stays buffered for replay
+		// once one reattaches.
+		return len(b), nil
+	}
+	if _, err := w.Write(wire); err != nil {
+		return 0, err
+	}
+	flusher.Flush()
+	return len(b), nil
+}
+
+func (t *httpTransport) Close() error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil
+	}
+	t.closed = true
+	t.mu.Unlock()
+	close(t.closeCh)
+	return nil
+}
+
+// SetReadDeadline is a no-op: there's no single socket here to set a
+// deadline on. ReadTimeout is instead enforced by the transport's own idle
+// timer (see newHTTPTransport/touch), which spans both the GET and POST
+// halves.
+func (t *httpTransport) SetReadDeadline(time.Time) error {
+	return nil
+}
+
+// FallbackHandler serves the same EventHandler/Conn message semantics as a
+// regular Upgrader, over two HTTP endpoints, for clients behind proxies that
+// block WebSocket: a long-lived GET streams frames down (chunked, or
+// Server-Sent Events when the request's Accept header asks for
+// text/event-stream), and a companion POST delivers frames up. The two
+// halves are tied together by a connection id issued on the first GET, and
+// Registry (not sticky sessions) is what lets a reconnecting POST land on
+// any node.
+type FallbackHandler struct {
+	Upgrader *Upgrader
+	Registry SessionRegistry
+
+	// KeepAlive is the interval at which an empty chunked write (or SSE
+	// event) is sent down the GET stream to hold proxies and load
+	// balancers open. Zero disables it.
+	KeepAlive time.Duration
+}
+
+// NewFallbackHandler builds a FallbackHandler, defaulting registry to a
+// single-node in-memory SessionRegistry when nil.
+func NewFallbackHandler(upgrader *Upgrader, registry SessionRegistry) *FallbackHandler {
+	if registry == nil {
+		registry = newMemorySessionRegistry()
+	}
+	return &FallbackHandler{Upgrader: upgrader, Registry: registry}
+}
+
+// ServeGET handles the long-lived streaming half of the fallback transport.
+// A request with no "cid" query parameter starts a new fallback Conn; one
+// carrying an existing id reattaches to it.
+func (h *FallbackHandler) ServeGET(w http.ResponseWriter, r *http.Request) {
+	connID := r.URL.Query().Get("cid")
+	sse := strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+
+	var socket *Conn
+	if connID != "" {
+		socket, _ = h.Registry.Load(connID)
+	}
+
+	var t *httpTransport
+	if socket == nil {
+		connID = newConnID()
+		option := h.Upgrader.option
+		config := option.getConfig()
+		t = newHTTPTransport(connID, sse, config.ReadTimeout)
+		socket = &Conn{
+			isServer:   true,
+			ss:         newSmap(),
+			config:     config,
+			conn:       t,
+			br:         bufio.NewReader(t),
+			handler:    h.Upgrader.EventHandler,
+			writeQueue: workerQueue{maxConcurrency: 1},
+			readQueue:  make(channel, defaultParallelGolimit),
+		}
+		socket.writeQueue.setCap(config.WriteQueueCap, config.WriteQueuePolicy)
+		h.Registry.Store(connID, socket)
+		go socket.ReadLoop()
+		if socket.handler != nil {
+			socket.handler.OnOpen(socket)
+		}
+	} else {
+		var ok bool
+		t, ok = socket.conn.(*httpTransport)
+		if !ok {
+			http.Error(w, "connection id in use by a different transport", http.StatusConflict)
+			return
+		}
+	}
+
+	if sse {
+		w.Header().Set("Content-Type", "text/event-stream")
+	} else {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Transfer-Encoding", "chunked")
+	}
+	w.Header().Set("X-Gbs-Conn-Id", connID)
+	w.WriteHeader(http.StatusOK)
+	// Flush the status line and X-Gbs-Conn-Id header to the client now:
+	// attachGET only flushes when there's a replay to send, so without
+	// this a client with nothing buffered for it would hang waiting for a
+	// response it already received in-process.
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	superseded, err := t.attachGET(w)
+	// attachGET sets t.w to w before it can fail (a replay write error), so
+	// detachGET must run on that path too or a broken writer is left
+	// attached for every later Write to trip over.
+	defer t.detachGET(w)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.runKeepAlive(t, r.Context().Done(), superseded)
+}
+
+// runKeepAlive blocks for as long as this GET is the one attached to t,
+// returning as soon as either the request itself ends (done), t closes, or a
+// reconnecting GET supersedes this one (superseded) — without the last case,
+// a reconnect would leak this goroutine until the stale request's underlying
+// connection independently timed out.
+func (h *FallbackHandler) runKeepAlive(t *httpTransport, done <-chan struct{}, superseded <-chan struct{}) {
+	if h.KeepAlive <= 0 {
+		select {
+		case <-done:
+		case <-t.closeCh:
+		case <-superseded:
+		}
+		return
+	}
+	ticker := time.NewTicker(h.KeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-t.closeCh:
+			return
+		case <-superseded:
+			return
+		case <-ticker.C:
+			_, _ = t.Write(nil)
+		}
+	}
+}
+
+// ServePOST delivers client frames uploaded for an existing fallback
+// connection identified by the "cid" query parameter.
+func (h *FallbackHandler) ServePOST(w http.ResponseWriter, r *http.Request) {
+	connID := r.URL.Query().Get("cid")
+	socket, ok := h.Registry.Load(connID)
+	if !ok {
+		http.Error(w, "unknown connection id", http.StatusNotFound)
+		return
+	}
+	t, ok := socket.conn.(*httpTransport)
+	if !ok {
+		http.Error(w, "connection id in use by a different transport", http.StatusConflict)
+		return
+	}
+
+	if ack := r.URL.Query().Get("ack"); ack != "" {
+		if n, err := strconv.ParseUint(ack, 10, 64); err == nil {
+			t.updateAck(n)
+		}
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	t.deliver(body)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func newConnID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,202 @@
+package gbs
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// encodeClientFrame builds a masked text frame the way a real browser client
+// would, for use as an httpTransport POST body in tests.
+func encodeClientFrame(payload []byte) []byte {
+	var fh frameHeader
+	n, _ := fh.GenerateHeader(true, false, true, OpcodeText, len(payload))
+	var mask [4]byte
+	_, _ = rand.Read(mask[:])
+	masked := make([]byte, len(payload))
+	for i, b := range payload {
+		masked[i] = b ^ mask[i%4]
+	}
+	buf := append([]byte{}, fh[:n]...)
+	buf = append(buf, mask[:]...)
+	buf = append(buf, masked...)
+	return buf
+}
+
+// decodeChunkedFrames splits the non-SSE wire format (8-byte seq + 4-byte
+// length + payload, repeated) a GET response body produces back into its
+// individual payloads.
+func decodeChunkedFrames(body []byte) [][]byte {
+	var frames [][]byte
+	for len(body) >= 12 {
+		n := binary.BigEndian.Uint32(body[8:12])
+		body = body[12:]
+		if uint32(len(body)) < n {
+			break
+		}
+		frames = append(frames, body[:n])
+		body = body[n:]
+	}
+	return frames
+}
+
+func startGET(fh *FallbackHandler, cid string) (rec *httptest.ResponseRecorder, cancel context.CancelFunc, done <-chan struct{}) {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	url := "/ws"
+	if cid != "" {
+		url += "?cid=" + cid
+	}
+	req := httptest.NewRequest(http.MethodGet, url, nil).WithContext(ctx)
+	rec = httptest.NewRecorder()
+	ch := make(chan struct{})
+	go func() {
+		fh.ServeGET(rec, req)
+		close(ch)
+	}()
+	return rec, cancelFn, ch
+}
+
+// 测试 GET/POST 往返：POST 投递的帧经 ReadLoop 解析后应触发 OnMessage
+func TestFallbackHandler_RoundTrip(t *testing.T) {
+	as := assert.New(t)
+
+	handler := new(webSocketMocker)
+	received := make(chan []byte, 1)
+	handler.onMessage = func(socket *Conn, message *Message) {
+		received <- message.Bytes()
+	}
+	fh := NewFallbackHandler(NewUpgrader(handler, nil), nil)
+
+	rec, cancel, done := startGET(fh, "")
+	defer func() { cancel(); <-done }()
+
+	as.Eventually(func() bool { return rec.Header().Get("X-Gbs-Conn-Id") != "" }, time.Second, time.Millisecond)
+	connID := rec.Header().Get("X-Gbs-Conn-Id")
+
+	postRec := httptest.NewRecorder()
+	postReq := httptest.NewRequest(http.MethodPost, "/ws?cid="+connID, bytes.NewReader(encodeClientFrame([]byte("hello"))))
+	fh.ServePOST(postRec, postReq)
+	as.Equal(http.StatusAccepted, postRec.Code)
+
+	select {
+	case got := <-received:
+		as.Equal([]byte("hello"), got)
+	case <-time.After(time.Second):
+		t.Fatal("message never delivered")
+	}
+}
+
+// 测试重连重放：未 ack 的帧在新 GET 附着时应重新下发
+func TestFallbackHandler_ReplaysUnackedFramesOnReconnect(t *testing.T) {
+	as := assert.New(t)
+
+	handler := new(webSocketMocker)
+	fh := NewFallbackHandler(NewUpgrader(handler, nil), nil)
+
+	rec1, cancel1, done1 := startGET(fh, "")
+	as.Eventually(func() bool { return rec1.Header().Get("X-Gbs-Conn-Id") != "" }, time.Second, time.Millisecond)
+	connID := rec1.Header().Get("X-Gbs-Conn-Id")
+
+	socket, ok := fh.Registry.Load(connID)
+	as.True(ok)
+
+	cancel1()
+	<-done1
+
+	as.NoError(socket.WriteMessage(OpcodeText, []byte("missed")))
+
+	rec2, cancel2, done2 := startGET(fh, connID)
+	defer func() { cancel2(); <-done2 }()
+
+	as.Eventually(func() bool { return rec2.Body.Len() > 0 }, time.Second, time.Millisecond)
+	frames := decodeChunkedFrames(rec2.Body.Bytes())
+	as.Len(frames, 1)
+	as.Contains(string(frames[0]), "missed")
+}
+
+// 测试 ack 后已确认的帧不会在后续重连时重放
+func TestFallbackHandler_UpdateAckDropsAckedFrames(t *testing.T) {
+	as := assert.New(t)
+
+	handler := new(webSocketMocker)
+	fh := NewFallbackHandler(NewUpgrader(handler, nil), nil)
+
+	rec1, cancel1, done1 := startGET(fh, "")
+	as.Eventually(func() bool { return rec1.Header().Get("X-Gbs-Conn-Id") != "" }, time.Second, time.Millisecond)
+	connID := rec1.Header().Get("X-Gbs-Conn-Id")
+
+	socket, ok := fh.Registry.Load(connID)
+	as.True(ok)
+	as.NoError(socket.WriteMessage(OpcodeText, []byte("acked")))
+	as.NoError(socket.WriteMessage(OpcodeText, []byte("unacked")))
+
+	ackReq := httptest.NewRequest(http.MethodPost, "/ws?cid="+connID+"&ack=1", bytes.NewReader(nil))
+	ackRec := httptest.NewRecorder()
+	fh.ServePOST(ackRec, ackReq)
+	as.Equal(http.StatusAccepted, ackRec.Code)
+
+	cancel1()
+	<-done1
+
+	rec2, cancel2, done2 := startGET(fh, connID)
+	defer func() { cancel2(); <-done2 }()
+
+	as.Eventually(func() bool { return rec2.Body.Len() > 0 }, time.Second, time.Millisecond)
+	frames := decodeChunkedFrames(rec2.Body.Bytes())
+	as.Len(frames, 1)
+	as.Contains(string(frames[0]), "unacked")
+}
+
+// 测试重连会终止旧 GET 的 keepalive 循环，即使其请求 context 从未结束
+func TestFallbackHandler_ReattachStopsPreviousKeepAlive(t *testing.T) {
+	as := assert.New(t)
+
+	handler := new(webSocketMocker)
+	fh := NewFallbackHandler(NewUpgrader(handler, nil), nil)
+
+	req1 := httptest.NewRequest(http.MethodGet, "/ws", nil) // context.Background(): never canceled
+	rec1 := httptest.NewRecorder()
+	done1 := make(chan struct{})
+	go func() {
+		fh.ServeGET(rec1, req1)
+		close(done1)
+	}()
+
+	as.Eventually(func() bool { return rec1.Header().Get("X-Gbs-Conn-Id") != "" }, time.Second, time.Millisecond)
+	connID := rec1.Header().Get("X-Gbs-Conn-Id")
+
+	_, cancel2, done2 := startGET(fh, connID)
+	defer func() { cancel2(); <-done2 }()
+
+	select {
+	case <-done1:
+	case <-time.After(time.Second):
+		t.Fatal("reattach did not stop the previous GET's keepalive goroutine")
+	}
+}
+
+// 测试空闲超时：ReadTimeout 到期应关闭连接并触发 OnClose
+func TestFallbackHandler_IdleTimeout(t *testing.T) {
+	handler := new(webSocketMocker)
+	closed := make(chan struct{})
+	handler.onClose = func(socket *Conn, err error) {
+		close(closed)
+	}
+	fh := NewFallbackHandler(NewUpgrader(handler, &ServerOption{ReadTimeout: 30 * time.Millisecond}), nil)
+
+	_, cancel, done := startGET(fh, "")
+	defer func() { cancel(); <-done }()
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("idle timeout never closed the connection")
+	}
+}
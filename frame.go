@@ -0,0 +1,136 @@
+package gbs
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// frameHeader is a reusable buffer sized for the largest possible WebSocket
+// frame header: 2 base bytes + 8 extended-length bytes + 4 mask-key bytes.
+type frameHeader [14]byte
+
+// GenerateHeader encodes a frame header for the given fin/compress/masked/
+// opcode/length into the receiver and returns how many leading bytes of it
+// are in use. It does not write the mask key itself; callers that set
+// masked append the 4-byte key separately so the same header buffer can be
+// reused across writes.
+func (c *frameHeader) GenerateHeader(fin, compress, masked bool, opcode Opcode, length int) (headerLength int, err error) {
+	c[0] = 0
+	if fin {
+		c[0] |= 0x80
+	}
+	if compress {
+		c[0] |= 0x40
+	}
+	c[0] |= uint8(opcode)
+
+	var maskBit uint8
+	if masked {
+		maskBit = 0x80
+	}
+
+	switch {
+	case length <= 125:
+		c[1] = maskBit | uint8(length)
+		headerLength = 2
+	case length <= 65535:
+		c[1] = maskBit | 126
+		binary.BigEndian.PutUint16(c[2:4], uint16(length))
+		headerLength = 4
+	default:
+		c[1] = maskBit | 127
+		binary.BigEndian.PutUint64(c[2:10], uint64(length))
+		headerLength = 10
+	}
+	return headerLength, nil
+}
+
+// defaultMaxFrameSize bounds a single frame's payload when Config.MaxFrameSize
+// is unset, so a peer can't claim an arbitrary 64-bit length and force an
+// equally arbitrary allocation in readFrame.
+const defaultMaxFrameSize = 16 << 20 // 16 MiB
+
+// ErrFrameTooLarge is returned by readFrame when a frame's declared length
+// exceeds the connection's configured (or default) maximum.
+var ErrFrameTooLarge = errors.New("gbs: frame exceeds max frame size")
+
+// ErrCompressedFrame is returned by readFrame for any frame with an RSV bit
+// set: gbs negotiates no extensions, so per RFC 6455 section 5.2 any RSV bit
+// must fail the connection rather than be silently reinterpreted (RSV1 in
+// particular would otherwise have its still-compressed payload handed to the
+// handler as if it were the plain message, since gbs has no
+// permessage-deflate implementation to undo it).
+var ErrCompressedFrame = errors.New("gbs: compressed frames are not supported")
+
+// decodedHeader is the parsed form of a frame header read off the wire.
+type decodedHeader struct {
+	fin    bool
+	opcode Opcode
+	masked bool
+	mask   [4]byte
+	length int
+}
+
+// readFrame reads and unmasks (if necessary) the next frame from c.br.
+func (c *Conn) readFrame() (decodedHeader, []byte, error) {
+	var h decodedHeader
+
+	b0, err := c.br.ReadByte()
+	if err != nil {
+		return h, nil, err
+	}
+	b1, err := c.br.ReadByte()
+	if err != nil {
+		return h, nil, err
+	}
+	h.fin = b0&0x80 != 0
+	if b0&0x70 != 0 { // RSV1|RSV2|RSV3: no extension negotiates any of them
+		return h, nil, ErrCompressedFrame
+	}
+	h.opcode = Opcode(b0 & 0x0f)
+	h.masked = b1&0x80 != 0
+
+	length := int(b1 & 0x7f)
+	switch length {
+	case 126:
+		var buf [2]byte
+		if _, err := io.ReadFull(c.br, buf[:]); err != nil {
+			return h, nil, err
+		}
+		length = int(binary.BigEndian.Uint16(buf[:]))
+	case 127:
+		var buf [8]byte
+		if _, err := io.ReadFull(c.br, buf[:]); err != nil {
+			return h, nil, err
+		}
+		length = int(binary.BigEndian.Uint64(buf[:]))
+	}
+	h.length = length
+
+	maxFrameSize := defaultMaxFrameSize
+	if c.config != nil && c.config.MaxFrameSize > 0 {
+		maxFrameSize = c.config.MaxFrameSize
+	}
+	// A 64-bit length with the top bit set converts to a negative int.
+	if length < 0 || length > maxFrameSize {
+		return h, nil, ErrFrameTooLarge
+	}
+
+	if h.masked {
+		if _, err := io.ReadFull(c.br, h.mask[:]); err != nil {
+			return h, nil, err
+		}
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return h, nil, err
+	}
+	if h.masked {
+		for i := range payload {
+			payload[i] ^= h.mask[i%4]
+		}
+	}
+	return h, payload, nil
+}
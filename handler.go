@@ -0,0 +1,20 @@
+package gbs
+
+// EventHandler receives the lifecycle and message callbacks for a Conn.
+type EventHandler interface {
+	OnOpen(socket *Conn)
+	OnClose(socket *Conn, err error)
+	OnPing(socket *Conn, payload []byte)
+	OnPong(socket *Conn, payload []byte)
+	OnMessage(socket *Conn, message *Message)
+}
+
+// BuiltinEventHandler is a no-op EventHandler meant to be embedded so callers
+// only have to override the callbacks they actually care about.
+type BuiltinEventHandler struct{}
+
+func (b BuiltinEventHandler) OnOpen(socket *Conn)                      {}
+func (b BuiltinEventHandler) OnClose(socket *Conn, err error)          {}
+func (b BuiltinEventHandler) OnPing(socket *Conn, payload []byte)      {}
+func (b BuiltinEventHandler) OnPong(socket *Conn, payload []byte)      {}
+func (b BuiltinEventHandler) OnMessage(socket *Conn, message *Message) {}
@@ -0,0 +1,232 @@
+package gbs
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+var errHubBackpressure = errors.New("gbs: hub disconnected slow subscriber")
+
+// HubOverflowPolicy controls what Hub.Publish does when a subscriber's
+// outbound queue is full because that connection is reading slower than the
+// hub is publishing to it.
+type HubOverflowPolicy uint8
+
+const (
+	// HubDropOldest discards the oldest not-yet-sent frame to make room for
+	// the new one.
+	HubDropOldest HubOverflowPolicy = iota
+	// HubDisconnect unsubscribes and closes the subscriber instead of
+	// queuing further frames for it.
+	HubDisconnect
+)
+
+// HubOption configures a Hub's per-subscriber backpressure handling.
+type HubOption struct {
+	// QueueSize bounds how many not-yet-sent frames Hub holds for a single
+	// subscriber before OverflowPolicy kicks in. Zero means 1.
+	QueueSize int
+	// OverflowPolicy selects what happens on overflow. Zero value is
+	// HubDropOldest.
+	OverflowPolicy HubOverflowPolicy
+}
+
+// Hub tracks connections by topic and broadcasts to them, sharing a single
+// encode pass across every subscriber instead of paying for it once per
+// WriteAsync call, the way a naive fan-out loop would.
+type Hub struct {
+	option HubOption
+
+	mu     sync.RWMutex
+	topics map[string]map[*Conn]*hubQueue
+
+	nextMessageID uint64
+
+	// OnAck is invoked when HandleAck recognizes a subscriber's {"ack": id}
+	// frame, enabling at-least-once delivery patterns built on Publish.
+	OnAck func(socket *Conn, id uint64)
+
+	// OnDrop is invoked whenever a queued frame is dropped, or a subscriber
+	// is disconnected for falling behind, per OverflowPolicy.
+	OnDrop func(socket *Conn, topic string)
+}
+
+// NewHub builds a Hub. The zero HubOption is valid and behaves as
+// QueueSize=1, OverflowPolicy=HubDropOldest.
+func NewHub(option HubOption) *Hub {
+	if option.QueueSize <= 0 {
+		option.QueueSize = 1
+	}
+	return &Hub{option: option, topics: make(map[string]map[*Conn]*hubQueue)}
+}
+
+// hubQueue is a bounded, drop-aware outbound queue for one (topic, conn)
+// pair.
+type hubQueue struct {
+	mu      sync.Mutex
+	frames  []*PreparedMessage
+	sending bool
+}
+
+// Subscribe adds socket as a subscriber of topic. Both the call and the
+// membership it establishes are O(1). socket must not already be closed:
+// Subscribe hooks its close to unsubscribe it automatically, but that hook
+// only fires on a close that happens after this call.
+func (h *Hub) Subscribe(topic string, socket *Conn) {
+	h.mu.Lock()
+	subs, ok := h.topics[topic]
+	if !ok {
+		subs = make(map[*Conn]*hubQueue)
+		h.topics[topic] = subs
+	}
+	_, already := subs[socket]
+	if !already {
+		subs[socket] = &hubQueue{}
+	}
+	h.mu.Unlock()
+
+	if !already {
+		socket.onClose(func(error) {
+			h.Unsubscribe(topic, socket)
+		})
+	}
+}
+
+// Unsubscribe removes socket from topic.
+func (h *Hub) Unsubscribe(topic string, socket *Conn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if subs, ok := h.topics[topic]; ok {
+		delete(subs, socket)
+		if len(subs) == 0 {
+			delete(h.topics, topic)
+		}
+	}
+}
+
+// Publish encodes payload into a PreparedMessage exactly once and fans it
+// out to every current subscriber of topic, returning a server-assigned
+// monotonic message id. The id is not written into the frame: subscribers
+// receive payload unchanged. HandleAck recognizes an {"ack": id} frame
+// carrying such an id.
+func (h *Hub) Publish(topic string, op Opcode, payload []byte) (messageID uint64, err error) {
+	messageID = atomic.AddUint64(&h.nextMessageID, 1)
+
+	prepared, err := PrepareMessage(op, payload)
+	if err != nil {
+		return messageID, err
+	}
+
+	h.mu.RLock()
+	queues := make(map[*Conn]*hubQueue, len(h.topics[topic]))
+	for socket, q := range h.topics[topic] {
+		queues[socket] = q
+	}
+	h.mu.RUnlock()
+
+	for socket, q := range queues {
+		h.enqueue(topic, socket, q, prepared)
+	}
+	return messageID, nil
+}
+
+func (h *Hub) enqueue(topic string, socket *Conn, q *hubQueue, frame *PreparedMessage) {
+	q.mu.Lock()
+	if len(q.frames) >= h.option.QueueSize {
+		if h.option.OverflowPolicy == HubDisconnect {
+			q.mu.Unlock()
+			if h.OnDrop != nil {
+				h.OnDrop(socket, topic)
+			}
+			h.Unsubscribe(topic, socket)
+			socket.close(errHubBackpressure)
+			return
+		}
+		q.frames = q.frames[1:]
+		if h.OnDrop != nil {
+			h.OnDrop(socket, topic)
+		}
+	}
+	q.frames = append(q.frames, frame)
+	sending := q.sending
+	q.sending = true
+	q.mu.Unlock()
+
+	if !sending {
+		go h.drain(topic, socket, q)
+	}
+}
+
+// drain writes queued frames for socket one at a time via WritePrepared,
+// since each one is already fully encoded — this is the "encode once, write
+// N times" path Publish exists for. ErrMessageDropped usually means
+// socket's own writeQueue was momentarily full (per its
+// Config.WriteQueuePolicy) and is not a sign socket is gone, so drain just
+// moves on to the next frame — except when that policy is CloseConn, which
+// already closed socket before returning the same error, so drain checks
+// isClosed to tell the two apart instead of trusting the error value alone.
+// Any other error means socket is gone (or going), so drain unsubscribes it
+// instead of leaving q.sending stuck true, which would otherwise wedge the
+// queue: every later enqueue would see sending already true and never
+// restart drain.
+func (h *Hub) drain(topic string, socket *Conn, q *hubQueue) {
+	for {
+		q.mu.Lock()
+		if len(q.frames) == 0 {
+			q.sending = false
+			q.mu.Unlock()
+			return
+		}
+		frame := q.frames[0]
+		q.frames = q.frames[1:]
+		q.mu.Unlock()
+
+		err := socket.WritePrepared(frame)
+		if err == nil {
+			continue
+		}
+		if errors.Is(err, ErrMessageDropped) && !socket.isClosed() {
+			if h.OnDrop != nil {
+				h.OnDrop(socket, topic)
+			}
+			continue
+		}
+
+		q.mu.Lock()
+		q.sending = false
+		q.mu.Unlock()
+		if h.OnDrop != nil {
+			h.OnDrop(socket, topic)
+		}
+		h.Unsubscribe(topic, socket)
+		socket.close(err)
+		return
+	}
+}
+
+type ackFrame struct {
+	Ack uint64 `json:"ack"`
+}
+
+// HandleAck inspects message for a {"ack": <id>} frame and, if present,
+// invokes OnAck and reports handled=true so the caller can skip normal
+// message processing for it. Wire it in from EventHandler.OnMessage:
+//
+//	func (h *myHandler) OnMessage(socket *gbs.Conn, message *gbs.Message) {
+//		if hub.HandleAck(socket, message) {
+//			return
+//		}
+//		// ... handle application messages
+//	}
+func (h *Hub) HandleAck(socket *Conn, message *Message) (handled bool) {
+	var f ackFrame
+	if err := json.Unmarshal(message.Bytes(), &f); err != nil || f.Ack == 0 {
+		return false
+	}
+	if h.OnAck != nil {
+		h.OnAck(socket, f.Ack)
+	}
+	return true
+}
@@ -0,0 +1,80 @@
+package gbs
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 测试广播：慢订阅者不应阻塞其它订阅者
+func TestHub_Publish(t *testing.T) {
+	as := assert.New(t)
+
+	hub := NewHub(HubOption{QueueSize: 4, OverflowPolicy: HubDropOldest})
+
+	const subscriberCount = 3
+	var received sync.WaitGroup
+	received.Add(subscriberCount)
+
+	for i := 0; i < subscriberCount; i++ {
+		clientHandler := new(webSocketMocker)
+		clientHandler.onMessage = func(socket *Conn, message *Message) {
+			received.Done()
+		}
+		server, client := newPeer(new(webSocketMocker), &ServerOption{}, clientHandler, &ClientOption{})
+		hub.Subscribe("room", server)
+
+		go server.ReadLoop()
+		if i == 0 {
+			// 一个慢订阅者：延迟读取，不应影响其它订阅者收到广播
+			time.AfterFunc(200*time.Millisecond, func() { go client.ReadLoop() })
+		} else {
+			go client.ReadLoop()
+		}
+	}
+
+	_, err := hub.Publish("room", OpcodeText, []byte("hello"))
+	as.NoError(err)
+
+	done := make(chan struct{})
+	go func() {
+		received.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("slow subscriber stalled the broadcast")
+	}
+}
+
+// 测试订阅者断开后不会永久卡住队列：写入失败应重置 sending 并自动退订
+func TestHub_Publish_DeadSubscriberIsUnsubscribed(t *testing.T) {
+	as := assert.New(t)
+
+	hub := NewHub(HubOption{QueueSize: 4})
+	server, client := newPeer(new(webSocketMocker), &ServerOption{}, new(webSocketMocker), &ClientOption{})
+	hub.Subscribe("room", server)
+	go server.ReadLoop()
+
+	// client 消失且从不读取：server 一侧的下一次写入会失败
+	as.NoError(client.NetConn().Close())
+
+	for i := 0; i < 50; i++ {
+		_, err := hub.Publish("room", OpcodeText, []byte("x"))
+		as.NoError(err)
+
+		hub.mu.RLock()
+		subs, ok := hub.topics["room"]
+		_, stillSubscribed := subs[server]
+		hub.mu.RUnlock()
+		if !ok || !stillSubscribed {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("dead subscriber was never unsubscribed")
+}
@@ -0,0 +1,40 @@
+package internal
+
+import (
+	"math/rand"
+	"time"
+)
+
+// AlphabetNumeric generates random alphanumeric payloads, used by tests that
+// need cheap, varied message bodies.
+var AlphabetNumeric = newAlphabet("0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
+
+// Alphabet draws bytes and lengths from a fixed character set.
+type Alphabet struct {
+	table []byte
+	rng   *rand.Rand
+}
+
+func newAlphabet(table string) *Alphabet {
+	return &Alphabet{
+		table: []byte(table),
+		rng:   rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Intn returns a random int in [0, n).
+func (c *Alphabet) Intn(n int) int {
+	if n <= 0 {
+		return 0
+	}
+	return c.rng.Intn(n)
+}
+
+// Generate returns n random bytes drawn from the alphabet's table.
+func (c *Alphabet) Generate(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = c.table[c.rng.Intn(len(c.table))]
+	}
+	return b
+}
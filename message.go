@@ -0,0 +1,26 @@
+package gbs
+
+import "bytes"
+
+// Opcode identifies a WebSocket frame's payload type, per RFC 6455 §11.8.
+type Opcode uint8
+
+const (
+	OpcodeContinuation    Opcode = 0x0
+	OpcodeText            Opcode = 0x1
+	OpcodeBinary          Opcode = 0x2
+	OpcodeCloseConnection Opcode = 0x8
+	OpcodePing            Opcode = 0x9
+	OpcodePong            Opcode = 0xA
+)
+
+// Message is a fully reassembled, unmasked frame handed to EventHandler.OnMessage.
+type Message struct {
+	Opcode Opcode
+	Data   *bytes.Buffer
+}
+
+// Bytes returns the message payload.
+func (c *Message) Bytes() []byte {
+	return c.Data.Bytes()
+}
@@ -0,0 +1,42 @@
+package gbs
+
+// webSocketMocker is a configurable EventHandler for tests: each On* callback
+// is a no-op unless the matching field is set.
+type webSocketMocker struct {
+	BuiltinEventHandler
+	onOpen    func(socket *Conn)
+	onClose   func(socket *Conn, err error)
+	onPing    func(socket *Conn, payload []byte)
+	onPong    func(socket *Conn, payload []byte)
+	onMessage func(socket *Conn, message *Message)
+}
+
+func (c *webSocketMocker) OnOpen(socket *Conn) {
+	if c.onOpen != nil {
+		c.onOpen(socket)
+	}
+}
+
+func (c *webSocketMocker) OnClose(socket *Conn, err error) {
+	if c.onClose != nil {
+		c.onClose(socket, err)
+	}
+}
+
+func (c *webSocketMocker) OnPing(socket *Conn, payload []byte) {
+	if c.onPing != nil {
+		c.onPing(socket, payload)
+	}
+}
+
+func (c *webSocketMocker) OnPong(socket *Conn, payload []byte) {
+	if c.onPong != nil {
+		c.onPong(socket, payload)
+	}
+}
+
+func (c *webSocketMocker) OnMessage(socket *Conn, message *Message) {
+	if c.onMessage != nil {
+		c.onMessage(socket, message)
+	}
+}
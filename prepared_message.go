@@ -0,0 +1,66 @@
+package gbs
+
+import (
+	"crypto/rand"
+	"sync"
+)
+
+// PreparedMessage holds a fully-encoded WebSocket frame (header + payload)
+// so that broadcasting it to many connections with Conn.WritePrepared pays
+// the encode/compress cost exactly once instead of once per connection.
+type PreparedMessage struct {
+	Opcode  Opcode
+	payload []byte
+
+	serverFrame []byte // unmasked frame, shared as-is across server Conns
+
+	clientOnce  sync.Once
+	clientFrame []byte
+}
+
+// PrepareMessage encodes op/payload into a frame once. The result is safe
+// for concurrent use by Conn.WritePrepared across many connections.
+func PrepareMessage(op Opcode, payload []byte) (*PreparedMessage, error) {
+	var fh frameHeader
+	n, err := fh.GenerateHeader(true, false, false, op, len(payload))
+	if err != nil {
+		return nil, err
+	}
+	frame := make([]byte, 0, n+len(payload))
+	frame = append(frame, fh[:n]...)
+	frame = append(frame, payload...)
+	return &PreparedMessage{Opcode: op, payload: payload, serverFrame: frame}, nil
+}
+
+// frameFor returns the wire bytes for socket: the shared unmasked frame for
+// a server Conn, or a masked variant for a client Conn.
+//
+// WritePrepared is primarily a server-side broadcast API. For a client Conn
+// the mask key is generated once and cached for the PreparedMessage's
+// lifetime rather than per write, trading the usual per-frame mask
+// randomness for the whole point of a PreparedMessage: paying the encode
+// cost once no matter how many times it's sent.
+func (p *PreparedMessage) frameFor(socket *Conn) []byte {
+	if socket.isServer {
+		return p.serverFrame
+	}
+
+	p.clientOnce.Do(func() {
+		var mask [4]byte
+		_, _ = rand.Read(mask[:])
+
+		var fh frameHeader
+		n, _ := fh.GenerateHeader(true, false, true, p.Opcode, len(p.payload))
+		frame := make([]byte, 0, n+4+len(p.payload))
+		frame = append(frame, fh[:n]...)
+		frame = append(frame, mask[:]...)
+
+		masked := make([]byte, len(p.payload))
+		for i, b := range p.payload {
+			masked[i] = b ^ mask[i%4]
+		}
+		frame = append(frame, masked...)
+		p.clientFrame = frame
+	})
+	return p.clientFrame
+}
@@ -0,0 +1,155 @@
+package gbs
+
+import (
+	"errors"
+	"sync"
+)
+
+// defaultParallelGolimit bounds how many read-side callbacks may run
+// concurrently for a single Conn when parallel message handling is enabled.
+const defaultParallelGolimit = 8
+
+// ErrMessageDropped is the error WriteAsync's callback receives when
+// WriteQueuePolicy chose to drop the message rather than queue it.
+var ErrMessageDropped = errors.New("gbs: message dropped, write queue is full")
+
+// ErrWriteQueueFull is passed to Conn.close when WriteQueuePolicy is
+// CloseConn and the write queue overflows.
+var ErrWriteQueueFull = errors.New("gbs: write queue is full")
+
+// queuedJob pairs a job with what to do instead of running it, if it has to
+// be evicted from the queue before its turn comes up.
+type queuedJob struct {
+	run    func()
+	reject func()
+}
+
+// workerQueue runs pushed jobs with bounded concurrency while preserving,
+// within a single concurrency slot, FIFO order. Conn.writeQueue uses a
+// maxConcurrency of 1 to guarantee frames for a connection are written in
+// the order they were queued.
+//
+// By default it grows without bound; setCap opts a queue into enforcing a
+// capacity and WriteQueuePolicy instead, which is what backs
+// Config.WriteQueueCap/WriteQueuePolicy.
+type workerQueue struct {
+	mu             sync.Mutex
+	cond           *sync.Cond
+	maxConcurrency int
+	curConcurrency int
+	jobs           []queuedJob
+
+	capacity int
+	policy   WriteQueuePolicy
+}
+
+func newWorkerQueue(maxConcurrency int) *workerQueue {
+	return &workerQueue{maxConcurrency: maxConcurrency}
+}
+
+// setCap bounds how many jobs may be queued or in flight at once and
+// selects what pushRejectable does when that bound would be exceeded. It is
+// meant to be set once, at Conn construction time, before the queue sees
+// any traffic; cap <= 0 means unbounded.
+func (q *workerQueue) setCap(capacity int, policy WriteQueuePolicy) {
+	q.mu.Lock()
+	q.capacity = capacity
+	q.policy = policy
+	q.mu.Unlock()
+}
+
+// Push schedules job to run as soon as a concurrency slot is free. It is
+// pushRejectable with no reject, so on a queue capped via setCap a job the
+// policy turns away is discarded without notice. TestTaskQueue/TestRQueue
+// exercise it directly on uncapped queues.
+func (q *workerQueue) Push(job func()) {
+	q.pushRejectable(job, nil)
+}
+
+// pushRejectable is Push with capacity enforcement: once the queue holds
+// cap jobs (queued or running), WriteQueuePolicy decides what happens next.
+// BlockProducer waits for room so the caller gets real backpressure,
+// DropOldest evicts the oldest queued job (invoking its reject) to make
+// room for job, or rejects job itself when every slot is taken by a running
+// job and nothing is queued to evict, and DropNewest/CloseConn invoke reject
+// instead of ever queuing job.
+func (q *workerQueue) pushRejectable(job func(), reject func()) {
+	q.mu.Lock()
+	if q.cond == nil {
+		q.cond = sync.NewCond(&q.mu)
+	}
+	for q.capacity > 0 && q.curConcurrency+len(q.jobs) >= q.capacity {
+		switch q.policy {
+		case DropOldest:
+			if len(q.jobs) == 0 {
+				// Only running jobs hold the capacity, so job itself is the
+				// oldest write not yet started.
+				q.mu.Unlock()
+				if reject != nil {
+					reject()
+				}
+				return
+			}
+			dropped := q.jobs[0]
+			q.jobs = q.jobs[1:]
+			q.mu.Unlock()
+			if dropped.reject != nil {
+				dropped.reject()
+			}
+			q.mu.Lock()
+		case DropNewest, CloseConn:
+			q.mu.Unlock()
+			if reject != nil {
+				reject()
+			}
+			return
+		default: // BlockProducer
+			q.cond.Wait()
+		}
+	}
+
+	if q.curConcurrency >= q.maxConcurrency {
+		q.jobs = append(q.jobs, queuedJob{run: job, reject: reject})
+		q.mu.Unlock()
+		return
+	}
+	q.curConcurrency++
+	q.mu.Unlock()
+	go q.runLoop(job)
+}
+
+func (q *workerQueue) runLoop(job func()) {
+	for job != nil {
+		job()
+		q.mu.Lock()
+		if len(q.jobs) == 0 {
+			q.curConcurrency--
+			if q.cond != nil {
+				q.cond.Broadcast()
+			}
+			q.mu.Unlock()
+			return
+		}
+		next := q.jobs[0]
+		q.jobs = q.jobs[1:]
+		if q.cond != nil {
+			q.cond.Broadcast()
+		}
+		q.mu.Unlock()
+		job = next.run
+	}
+}
+
+// channel is a semaphore-backed dispatcher used to bound how many read-side
+// callbacks run concurrently for a single Conn: its buffer capacity is the
+// concurrency limit.
+type channel chan struct{}
+
+// Go runs fn(message) in its own goroutine once a slot is available.
+func (c channel) Go(message *Message, fn func(message *Message) error) {
+	c <- struct{}{}
+	go func() {
+		defer func() { <-c }()
+		_ = fn(message)
+	}()
+}
@@ -0,0 +1,62 @@
+package gbs
+
+import "net"
+
+// Server owns a raw TCP Accept loop and upgrades each connection with an
+// Upgrader. Driving Accept ourselves (rather than relying on net/http) is
+// what lets ServerOption.OnConnect see a connection before any bufio read or
+// HTTP parsing happens.
+type Server struct {
+	upgrader *Upgrader
+	listener net.Listener
+}
+
+// NewServer wraps upgrader with an Accept loop over listener.
+func NewServer(listener net.Listener, upgrader *Upgrader) *Server {
+	return &Server{upgrader: upgrader, listener: listener}
+}
+
+// Run accepts connections until the listener is closed or Accept errors.
+//
+// Each accepted connection gets exactly one OnConnect call, invoked once per
+// raw TCP accept (never retried across upgrade attempts) and before any
+// bufio buffer is allocated for the handshake. Returning a non-nil error
+// from OnConnect closes the connection and invokes OnError instead of
+// attempting the handshake; a handshake failure after OnConnect succeeds
+// also invokes OnError.
+func (s *Server) Run() error {
+	option := s.upgrader.option
+	for {
+		netConn, err := s.listener.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handleConn(netConn, option)
+	}
+}
+
+func (s *Server) handleConn(netConn net.Conn, option *ServerOption) {
+	if option.OnConnect != nil {
+		if err := option.OnConnect(netConn); err != nil {
+			_ = netConn.Close()
+			if option.OnError != nil {
+				option.OnError(netConn, err)
+			}
+			return
+		}
+	}
+
+	socket, err := s.upgrader.Upgrade(netConn)
+	if err != nil {
+		_ = netConn.Close()
+		if option.OnError != nil {
+			option.OnError(netConn, err)
+		}
+		return
+	}
+
+	if socket.handler != nil {
+		socket.handler.OnOpen(socket)
+	}
+	socket.ReadLoop()
+}
@@ -0,0 +1,39 @@
+package gbs
+
+import "sync"
+
+// SessionStorage is a per-connection key/value store that caller code can use
+// to stash state (auth identity, room id, ...) alongside a Conn.
+type SessionStorage interface {
+	Load(key string) (value interface{}, exists bool)
+	Store(key string, value interface{})
+	Delete(key string)
+	Range(f func(key string, value interface{}) bool)
+}
+
+// smap is the default SessionStorage, backed by sync.Map.
+type smap struct {
+	m sync.Map
+}
+
+func newSmap() *smap {
+	return &smap{}
+}
+
+func (c *smap) Load(key string) (interface{}, bool) {
+	return c.m.Load(key)
+}
+
+func (c *smap) Store(key string, value interface{}) {
+	c.m.Store(key, value)
+}
+
+func (c *smap) Delete(key string) {
+	c.m.Delete(key)
+}
+
+func (c *smap) Range(f func(key string, value interface{}) bool) {
+	c.m.Range(func(k, v interface{}) bool {
+		return f(k.(string), v)
+	})
+}
@@ -2,6 +2,7 @@ package gbs
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"net"
 	"sync"
@@ -29,7 +30,6 @@ func serveWebSocket(
 		conn:        netConn,
 		closed:      0,
 		br:          br,
-		fh:          frameHeader{},
 		handler:     handler,
 		subprotocol: subprotocol,
 		writeQueue:  workerQueue{maxConcurrency: 1},
@@ -148,7 +148,7 @@ func TestConn_WriteAsync(t *testing.T) {
 
 		{
 			fh := frameHeader{}
-			n, _ := fh.GenerateHeader(true, true, OpcodeText, 0)
+			n, _ := fh.GenerateHeader(true, true, false, OpcodeText, 0)
 			go func() { client.conn.Write(fh[:n]) }()
 		}
 
@@ -339,3 +339,98 @@ func TestRQueue(t *testing.T) {
 		<-done
 	})
 }
+
+// 测试 WritePrepared 广播：多个发送方复用同一个 *PreparedMessage
+func TestConn_WritePrepared(t *testing.T) {
+	as := assert.New(t)
+
+	const senders = 4
+	message := internal.AlphabetNumeric.Generate(64)
+	prepared, err := PrepareMessage(OpcodeBinary, message)
+	as.NoError(err)
+
+	var wg sync.WaitGroup
+	wg.Add(senders)
+
+	for i := 0; i < senders; i++ {
+		clientHandler := new(webSocketMocker)
+		clientHandler.onMessage = func(socket *Conn, got *Message) {
+			as.Equal(message, got.Bytes())
+			wg.Done()
+		}
+		server, client := newPeer(new(webSocketMocker), &ServerOption{}, clientHandler, &ClientOption{})
+		go server.ReadLoop()
+		go client.ReadLoop()
+
+		as.NoError(server.WritePrepared(prepared))
+	}
+
+	wg.Wait()
+}
+
+// 测试写队列容量策略：DropOldest 丢弃旧消息而不是无限增长/阻塞
+func TestConn_WriteAsync_DropOldest(t *testing.T) {
+	as := assert.New(t)
+
+	var dropped int32
+	serverOption := initServerOption(&ServerOption{
+		OnDrop: func(socket *Conn, opcode Opcode, payload []byte) {
+			atomic.AddInt32(&dropped, 1)
+		},
+	})
+	server, client := newPeer(new(webSocketMocker), &ServerOption{}, new(webSocketMocker), &ClientOption{})
+	server.config = serverOption.getConfig()
+	server.writeQueue.setCap(2, DropOldest)
+
+	// 不启动 client.ReadLoop：第一条消息会一直阻塞在 writeFrame 里，
+	// 后续消息填满队列后触发 DropOldest。reject 在 WriteAsync 调用栈里
+	// 同步执行，因此循环结束时所有该发生的丢弃都已经发生。
+	const total = 5
+	var droppedCallbacks int32
+	for i := 0; i < total; i++ {
+		server.WriteAsync(OpcodeBinary, []byte{byte(i)}, func(err error) {
+			if errors.Is(err, ErrMessageDropped) {
+				atomic.AddInt32(&droppedCallbacks, 1)
+			}
+		})
+	}
+
+	as.Greater(int(atomic.LoadInt32(&dropped)), 0)
+	as.Equal(int(atomic.LoadInt32(&dropped)), int(atomic.LoadInt32(&droppedCallbacks)))
+	_ = client
+}
+
+// 测试 WritePrepared 也遵循写队列容量策略，和 WriteAsync 一样
+func TestConn_WritePrepared_DropOldest(t *testing.T) {
+	as := assert.New(t)
+
+	var dropped int32
+	serverOption := initServerOption(&ServerOption{
+		OnDrop: func(socket *Conn, opcode Opcode, payload []byte) {
+			atomic.AddInt32(&dropped, 1)
+		},
+	})
+	server, client := newPeer(new(webSocketMocker), &ServerOption{}, new(webSocketMocker), &ClientOption{})
+	server.config = serverOption.getConfig()
+	server.writeQueue.setCap(2, DropOldest)
+
+	prepared, err := PrepareMessage(OpcodeBinary, []byte{1})
+	as.NoError(err)
+
+	// 不启动 client.ReadLoop：第一条消息会一直阻塞在 conn.Write 里，
+	// 后续消息填满队列后触发 DropOldest。
+	const total = 5
+	var droppedErrs int32
+	for i := 0; i < total; i++ {
+		go func() {
+			if err := server.WritePrepared(prepared); errors.Is(err, ErrMessageDropped) {
+				atomic.AddInt32(&droppedErrs, 1)
+			}
+		}()
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	as.Greater(int(atomic.LoadInt32(&dropped)), 0)
+	as.Equal(int(atomic.LoadInt32(&dropped)), int(atomic.LoadInt32(&droppedErrs)))
+	_ = client
+}
@@ -0,0 +1,15 @@
+package gbs
+
+import "time"
+
+// transport abstracts the byte stream a Conn reads frames from and writes
+// frames to. net.Conn satisfies it structurally, which is what regular
+// WebSocket connections use; httpTransport is the other implementation,
+// backing the HTTP-streaming/SSE fallback for clients behind proxies that
+// block WebSocket upgrades.
+type transport interface {
+	Read(b []byte) (n int, err error)
+	Write(b []byte) (n int, err error)
+	Close() error
+	SetReadDeadline(t time.Time) error
+}
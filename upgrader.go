@@ -0,0 +1,102 @@
+package gbs
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// websocketGUID is the magic value RFC 6455 has clients and servers append
+// to Sec-WebSocket-Key before hashing, so a response can't be produced
+// without having seen the request.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// acceptKey computes the Sec-WebSocket-Accept value for a handshake request's
+// Sec-WebSocket-Key header, per RFC 6455 section 1.3.
+func acceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key))
+	h.Write([]byte(websocketGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// Request is the subset of the incoming HTTP handshake gbs exposes to
+// CheckOrigin and similar hooks.
+type Request struct {
+	*http.Request
+}
+
+// Upgrader turns an already-accepted net.Conn into a WebSocket Conn by
+// performing the HTTP handshake.
+type Upgrader struct {
+	EventHandler EventHandler
+	option       *ServerOption
+	CheckOrigin  func(r *Request) bool
+}
+
+// NewUpgrader builds an Upgrader bound to handler, filling in option
+// defaults via initServerOption when option is nil.
+func NewUpgrader(handler EventHandler, option *ServerOption) *Upgrader {
+	return &Upgrader{
+		EventHandler: handler,
+		option:       initServerOption(option),
+	}
+}
+
+// Upgrade parses the HTTP handshake off netConn and returns the resulting
+// Conn. Admission-control hooks (ServerOption.OnConnect/OnError) run earlier,
+// in Server.Run, before Upgrade is ever called.
+func (u *Upgrader) Upgrade(netConn net.Conn) (*Conn, error) {
+	br := bufio.NewReader(netConn)
+	r, err := http.ReadRequest(br)
+	if err != nil {
+		return nil, err
+	}
+	if u.CheckOrigin != nil && !u.CheckOrigin(&Request{Request: r}) {
+		return nil, fmt.Errorf("gbs: origin rejected")
+	}
+
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		return nil, fmt.Errorf("gbs: missing Sec-WebSocket-Key")
+	}
+	subprotocol := r.Header.Get("Sec-WebSocket-Protocol")
+
+	if err := writeHandshakeResponse(netConn, key, subprotocol); err != nil {
+		return nil, err
+	}
+
+	config := u.option.getConfig()
+	socket := &Conn{
+		isServer:    true,
+		ss:          newSmap(),
+		config:      config,
+		conn:        netConn,
+		br:          br,
+		handler:     u.EventHandler,
+		subprotocol: subprotocol,
+		writeQueue:  workerQueue{maxConcurrency: 1},
+		readQueue:   make(channel, defaultParallelGolimit),
+	}
+	socket.writeQueue.setCap(config.WriteQueueCap, config.WriteQueuePolicy)
+	return socket, nil
+}
+
+// writeHandshakeResponse writes the HTTP/1.1 101 Switching Protocols
+// response that completes the RFC 6455 handshake, without which no real
+// WebSocket client ever finishes connecting.
+func writeHandshakeResponse(netConn net.Conn, key, subprotocol string) error {
+	resp := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n"
+	if subprotocol != "" {
+		resp += "Sec-WebSocket-Protocol: " + subprotocol + "\r\n"
+	}
+	resp += "\r\n"
+	_, err := netConn.Write([]byte(resp))
+	return err
+}
@@ -0,0 +1,129 @@
+package gbs
+
+import (
+	"bytes"
+	"crypto/rand"
+	"net"
+)
+
+// WriteAsync enqueues a write onto the connection's writeQueue, whose
+// concurrency of 1 preserves ordering between WriteAsync calls. callback, if
+// non-nil, receives the outcome once the frame has actually been written
+// (or failed to write) — or, when Config.WriteQueueCap is set and the queue
+// is full, ErrMessageDropped, per Config.WriteQueuePolicy.
+func (c *Conn) WriteAsync(opcode Opcode, data []byte, callback func(err error)) {
+	run := func() {
+		err := c.writeFrame(opcode, data)
+		if callback != nil {
+			callback(err)
+		}
+	}
+
+	reject := func() {
+		if c.config != nil {
+			if c.config.WriteQueuePolicy == CloseConn {
+				c.close(ErrWriteQueueFull)
+			}
+			if c.config.OnDrop != nil {
+				c.config.OnDrop(c, opcode, data)
+			}
+		}
+		if callback != nil {
+			callback(ErrMessageDropped)
+		}
+	}
+
+	c.writeQueue.pushRejectable(run, reject)
+}
+
+// WriteMessage writes opcode/data synchronously, bypassing the writeQueue.
+func (c *Conn) WriteMessage(opcode Opcode, data []byte) error {
+	return c.writeFrame(opcode, data)
+}
+
+// WriteString writes s as a text frame.
+func (c *Conn) WriteString(s string) error {
+	return c.writeFrame(OpcodeText, []byte(s))
+}
+
+// WritePing writes a ping control frame.
+func (c *Conn) WritePing(payload []byte) error {
+	return c.writeFrame(OpcodePing, payload)
+}
+
+// WritePong writes a pong control frame.
+func (c *Conn) WritePong(payload []byte) error {
+	return c.writeFrame(OpcodePong, payload)
+}
+
+// WritePrepared writes message's already-encoded frame bytes through the
+// writeQueue, so ordering relative to other WriteAsync/WritePrepared calls
+// on this Conn is preserved, without paying to re-encode or re-compress a
+// payload that PrepareMessage already encoded once for every recipient.
+// Config.WriteQueueCap/WriteQueuePolicy apply here exactly as they do to
+// WriteAsync, since broadcast via Hub is WritePrepared's main caller and the
+// path this backpressure exists for.
+func (c *Conn) WritePrepared(message *PreparedMessage) error {
+	if c.isClosed() {
+		return net.ErrClosed
+	}
+
+	frame := message.frameFor(c)
+	done := make(chan error, 1)
+
+	run := func() {
+		_, err := c.conn.Write(frame)
+		if err != nil {
+			c.close(err)
+		}
+		done <- err
+	}
+	reject := func() {
+		if c.config != nil {
+			if c.config.WriteQueuePolicy == CloseConn {
+				c.close(ErrWriteQueueFull)
+			}
+			if c.config.OnDrop != nil {
+				c.config.OnDrop(c, message.Opcode, message.payload)
+			}
+		}
+		done <- ErrMessageDropped
+	}
+
+	c.writeQueue.pushRejectable(run, reject)
+	return <-done
+}
+
+func (c *Conn) writeFrame(opcode Opcode, payload []byte) error {
+	if c.isClosed() {
+		return net.ErrClosed
+	}
+
+	// A local header, not a Conn-shared one: writeFrame is called both
+	// through writeQueue (WriteAsync) and directly, synchronously, by
+	// WriteMessage/WriteString/WritePing/WritePong, so a header shared
+	// across those call paths would race.
+	var fh frameHeader
+	n, _ := fh.GenerateHeader(true, false, !c.isServer, opcode, len(payload))
+	buf := bytes.NewBuffer(make([]byte, 0, n+len(payload)+4))
+	buf.Write(fh[:n])
+
+	if c.isServer {
+		buf.Write(payload)
+	} else {
+		var mask [4]byte
+		_, _ = rand.Read(mask[:])
+		masked := make([]byte, len(payload))
+		for i, b := range payload {
+			masked[i] = b ^ mask[i%4]
+		}
+		buf.Write(mask[:])
+		buf.Write(masked)
+	}
+
+	_, err := c.conn.Write(buf.Bytes())
+	if err != nil {
+		c.close(err)
+	}
+	return err
+}